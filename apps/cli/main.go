@@ -1,23 +1,32 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"image"
 	"image/draw"
 	"image/png"
 	"log"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"sync"
 
 	"github.com/disintegration/imaging"
+
+	wasmruntime "github.com/PhantomInTheWire/wasm-image-pipeline/pkg/runtime"
 )
 
 // Default grid size
 const rows = 4
 const cols = 4
 
+// filterImage is the wasm filter, packaged as an OCI image, run via the
+// containerd runwasi handler.
+const filterImage = "gray-filter:latest"
+
+// containerdSocket is where the local containerd daemon listens.
+const containerdSocket = "/run/containerd/containerd.sock"
+
 func main() {
 	if len(os.Args) < 3 {
 		fmt.Fprintf(os.Stderr, "Usage: %s <input.png> <output.png>\n", os.Args[0])
@@ -59,6 +68,12 @@ func main() {
 		}
 	}
 
+	rtClient, err := wasmruntime.New(containerdSocket)
+	if err != nil {
+		log.Fatalf("failed to connect to containerd: %v", err)
+	}
+	defer rtClient.Close()
+
 	// Process tiles in parallel
 	var wg sync.WaitGroup
 	processed := make([]string, len(tilePaths))
@@ -67,13 +82,19 @@ func main() {
 		go func(idx int, inFile string) {
 			defer wg.Done()
 
+			tileData, err := os.ReadFile(inFile)
+			if err != nil {
+				log.Fatalf("failed to read tile %s: %v", inFile, err)
+			}
+
+			outData, err := rtClient.RunTile(context.Background(), filterImage, tileData)
+			if err != nil {
+				log.Fatalf("failed to process tile %s: %v", inFile, err)
+			}
+
 			outFile := filepath.Join(tmpDir, fmt.Sprintf("proc_%s", filepath.Base(inFile)))
-			// Call OCI container (assumes gray-filter:latest reads args)
-			cmd := exec.Command("docker", "run", "--rm",
-				"-v", fmt.Sprintf("%s:/data", tmpDir),
-				"gray-filter:latest", "/data/"+filepath.Base(inFile), "/data/"+filepath.Base(outFile))
-			if out, err := cmd.CombinedOutput(); err != nil {
-				log.Fatalf("failed to process tile %s: %v, output: %s", inFile, err, string(out))
+			if err := os.WriteFile(outFile, outData, 0o644); err != nil {
+				log.Fatalf("failed to write processed tile %s: %v", outFile, err)
 			}
 
 			processed[idx] = outFile
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
@@ -11,25 +12,58 @@ import (
 	"github.com/PhantomInTheWire/wasm-image-pipeline/pkg/split"
 	"github.com/PhantomInTheWire/wasm-image-pipeline/pkg/storage"
 	"github.com/PhantomInTheWire/wasm-image-pipeline/pkg/kube"
+	"github.com/PhantomInTheWire/wasm-image-pipeline/pkg/wasmartifact"
 )
 
-func sanitizeJobName(tile string) string {
+// wasmFilterPath is the locally built filter module pushed as an OCI
+// artifact once per pipeline run.
+const wasmFilterPath = "./filter.wasm"
+
+// wasmArtifactRepo is the registry repository pipeline runs publish the
+// filter artifact to; Jobs pull it back by the digest-pinned ref returned
+// from the push.
+const wasmArtifactRepo = "ghcr.io/phantominthewire/filter:latest"
+
+// batchParallelism caps how many tile pods the Indexed Job runs at once.
+const batchParallelism int32 = 4
+
+// tileEventTimeout bounds how long the controller waits for every tile's
+// upload to be reported back before failing the run, rather than blocking
+// forever on a dropped or miscounted notification.
+const tileEventTimeout = 5 * time.Minute
+
+// maxTileEventErrors caps how many Event.Err occurrences the controller
+// tolerates before giving up on the run.
+const maxTileEventErrors = 10
+
+func sanitizeJobName(name string) string {
 	// Extract base without extension
-	base := strings.TrimSuffix(filepath.Base(tile), filepath.Ext(tile))
+	base := strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))
 
 	// Lowercase, replace invalid characters with "-"
 	sanitized := regexp.MustCompile(`[^a-z0-9-]`).ReplaceAllString(strings.ToLower(base), "-")
 	sanitized = strings.Trim(sanitized, "-")
+	return sanitized
+}
 
-	// Append timestamp for uniqueness
-	name := fmt.Sprintf("wasm-process-%s-%d", sanitized, time.Now().UnixNano())
+// runPrefix returns the bucket prefix this pipeline run uploads tiles under.
+// It's unique per run (rather than the fixed "job1" every run used to
+// share) so this run's ListenTileEvents subscription can't be handed a
+// stale or unrelated notification left over from a previous run against
+// the same image.
+func runPrefix(sanitizedName string, runID int64) string {
+	return fmt.Sprintf("%s-%d", sanitizedName, runID)
+}
 
-	// Ensure total length ≤ 63 characters
-	if len(name) > 63 {
-		name = name[:63]
+// batchJobName derives the Job name for a run from the same runID used for
+// its bucket prefix, so the two are traceable to each other, capped at the
+// 63-character Kubernetes name limit.
+func batchJobName(sanitizedName string, runID int64) string {
+	jobName := fmt.Sprintf("wasm-process-%s-%d", sanitizedName, runID)
+	if len(jobName) > 63 {
+		jobName = jobName[:63]
 	}
-
-	return name
+	return jobName
 }
 
 func main() {
@@ -41,12 +75,19 @@ func main() {
 	imagePath := os.Args[1]
 	outDir := "./shared/tiles"
 
-	tiles, err := split.Image(imagePath, outDir, 4)
+	tiles, err := split.Image(imagePath, 4)
 	if err != nil {
 		log.Fatalf("error splitting image: %v", err)
 	}
 
-	fmt.Println("Tiles created:", tiles)
+	tileNames, err := split.SaveTiles(tiles, outDir)
+	if err != nil {
+		log.Fatalf("error saving tiles: %v", err)
+	}
+	fmt.Println("Tiles created:", tileNames)
+
+	runID := time.Now().UnixNano()
+	sanitizedName := sanitizeJobName(imagePath)
 
 	minioCfg := storage.MinioConfig{
 		Endpoint:  "http://localhost:9000",
@@ -54,20 +95,71 @@ func main() {
 		AccessKey: "minioadmin",  // or from os.Getenv("MINIO_ACCESS_KEY")
 		SecretKey: "minioadmin",  // or from os.Getenv("MINIO_SECRET_KEY")
 		Bucket:    "tiles-bucket",
-		Prefix:    "job1", // you can make this dynamic based on timestamp or image name
+		Prefix:    runPrefix(sanitizedName, runID),
 		Dir:       outDir,
 	}
 
-	if err := storage.UploadTiles(minioCfg); err != nil {
+	// Subscribe before uploading: ListenBucketNotification only streams
+	// events going forward, so the controller needs to already be
+	// listening by the time UploadTilesParallel starts putting objects,
+	// or the tiles it just uploaded would never be reported back to it.
+	// Bounded by tileEventTimeout so a dropped/miscounted notification
+	// fails the run instead of hanging it forever.
+	ctx, cancel := context.WithTimeout(context.Background(), tileEventTimeout)
+	defer cancel()
+
+	events, err := storage.ListenTileEvents(ctx, minioCfg)
+	if err != nil {
+		log.Fatalf("failed to subscribe to tile events: %v", err)
+	}
+
+	if _, err := storage.UploadTilesParallel(minioCfg); err != nil {
 		log.Fatalf("failed to upload tiles to MinIO: %v", err)
 	}
-	for _, tile := range tiles {
-    jobName := sanitizeJobName(tile)
-    err := kube.CreateJobForTile(jobName, tile, "default", "http://minio.default.svc:9000/tiles-bucket")
-    if err != nil {
-        log.Printf("Failed to create job for tile %s: %v", tile, err)
-    } else {
-        log.Printf("Job created for tile: %s", tile)
-    }
-}
+	artifact, err := wasmartifact.Push(context.Background(), wasmartifact.NewResolver(), wasmArtifactRepo, wasmFilterPath)
+	if err != nil {
+		log.Fatalf("failed to push filter.wasm artifact: %v", err)
+	}
+	wasmArtifactRef := fmt.Sprintf("%s@%s", wasmArtifactRepo, artifact.Digest)
+	log.Printf("Published filter artifact: %s", wasmArtifactRef)
+
+	const bucketURL = "http://minio.default.svc:9000/tiles-bucket"
+	tileRefs := make([]kube.TileRef, 0, len(tiles))
+	errCount := 0
+collectEvents:
+	for len(tileRefs) < len(tiles) {
+		select {
+		case <-ctx.Done():
+			log.Fatalf("timed out after %s waiting for tile uploads to be reported (%d/%d tiles landed): %v",
+				tileEventTimeout, len(tileRefs), len(tiles), ctx.Err())
+		case ev, ok := <-events:
+			if !ok {
+				break collectEvents
+			}
+			if ev.Err != nil {
+				errCount++
+				log.Printf("tile event error (%d/%d tolerated): %v", errCount, maxTileEventErrors, ev.Err)
+				if errCount > maxTileEventErrors {
+					log.Fatalf("too many tile event errors, aborting run")
+				}
+				continue
+			}
+
+			tile := filepath.Base(ev.Key)
+			tileRefs = append(tileRefs, kube.TileRef{
+				Name:      tile,
+				InputURL:  fmt.Sprintf("%s/%s", bucketURL, tile),
+				OutputURL: fmt.Sprintf("%s/processed/%s", bucketURL, tile),
+			})
+		}
+	}
+	if len(tileRefs) < len(tiles) {
+		log.Fatalf("tile event stream closed early (%d/%d tiles landed)", len(tileRefs), len(tiles))
+	}
+
+	jobName := batchJobName(sanitizedName, runID)
+	if err := kube.CreateTileBatchJob(jobName, tileRefs, wasmArtifactRef, "default", batchParallelism); err != nil {
+		log.Fatalf("failed to create tile batch job: %v", err)
+	}
+	log.Printf("Batch job created: %s (%d tiles)", jobName, len(tileRefs))
 }
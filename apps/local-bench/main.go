@@ -4,7 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"image"
-	"image/color"
+	"image/draw"
 	"image/png"
 	"log"
 	"os"
@@ -13,6 +13,8 @@ import (
 
 	"github.com/disintegration/imaging"
 	"github.com/second-state/WasmEdge-go/wasmedge"
+
+	"github.com/PhantomInTheWire/wasm-image-pipeline/pkg/tilebuf"
 )
 
 var (
@@ -24,6 +26,13 @@ var (
 	maxWorkers = getEnvInt("MAX_WORKERS", 8)
 )
 
+// maxTileBytes bounds the module buffer a raw-ABI worker allocates once and
+// reuses for every tile: tileSize×tileSize NRGBA pixels, worst case.
+var maxTileBytes = int32(tileSize * tileSize * 4)
+
+// formatNRGBA8 is the `format` grayscale_raw expects: 8-bit NRGBA, row-major.
+const formatNRGBA8 = 0
+
 func getEnv(key, fallback string) string {
 	if v := os.Getenv(key); v != "" {
 		return v
@@ -52,16 +61,70 @@ func init() {
 	wasmedge.LoadPluginDefaultPaths()
 }
 
-func newVM(wasmPath string) *wasmedge.VM {
+// workerVM pairs a VM with the module buffer it reuses across tiles when
+// the loaded module exports grayscale_raw; workers whose module doesn't
+// export it fall back to the PNG-encoded ABI per tile.
+type workerVM struct {
+	vm     *wasmedge.VM
+	rawABI bool
+	buf    tilebuf.ModuleBuffer
+}
+
+func newWorkerVM(wasmPath string) *workerVM {
 	conf := wasmedge.NewConfigure(wasmedge.WASI)
 	vm := wasmedge.NewVMWithConfig(conf)
 	checkErr(vm.LoadWasmFile(wasmPath))
 	checkErr(vm.Validate())
 	checkErr(vm.Instantiate())
-	return vm
+
+	w := &workerVM{vm: vm}
+	mod := vm.GetActiveModule()
+	if mod.FindFunction("grayscale_raw") != nil {
+		res, err := vm.Execute("alloc", maxTileBytes)
+		checkErr(err)
+		w.rawABI = true
+		w.buf = tilebuf.ModuleBuffer{Ptr: res[0].(int32), Cap: maxTileBytes}
+	}
+	return w
 }
 
-func runTile(vm *wasmedge.VM, tile image.Image) (image.Image, error) {
+func (w *workerVM) release() { w.vm.Release() }
+
+// runTileRaw processes tile through grayscale_raw, writing pixels directly
+// into w's reused module buffer and reading the result straight into out
+// at origin — no PNG encode/decode and no intermediate image.Image.
+func runTileRaw(w *workerVM, tile *image.NRGBA, out *image.NRGBA, origin image.Point) error {
+	mod := w.vm.GetActiveModule()
+	mem := mod.FindMemory("memory")
+
+	width, height := int32(tile.Rect.Dx()), int32(tile.Rect.Dy())
+	stride, err := tilebuf.WriteTile(mem, w.buf, tile)
+	if err != nil {
+		return fmt.Errorf("stage tile: %w", err)
+	}
+
+	res, err := w.vm.Execute("grayscale_raw", w.buf.Ptr, width, height, stride, int32(formatNRGBA8))
+	if err != nil {
+		return fmt.Errorf("grayscale_raw: %w", err)
+	}
+	outPtr, outLen := res[0].(int32), res[1].(int32)
+	if outLen == 0 {
+		return fmt.Errorf("zero length output")
+	}
+
+	if err := tilebuf.ReadInto(mem, outPtr, outLen, out, origin, int(width), int(height)); err != nil {
+		return fmt.Errorf("read result: %w", err)
+	}
+
+	w.vm.Execute("dealloc", outPtr, outLen)
+	return nil
+}
+
+// runTilePNG is the v1 ABI fallback for modules that don't export
+// grayscale_raw: it PNG-encodes the tile, calls `grayscale`, and decodes
+// the result.
+func runTilePNG(w *workerVM, tile *image.NRGBA) (image.Image, error) {
+	vm := w.vm
 	var inBuf bytes.Buffer
 	if err := png.Encode(&inBuf, tile); err != nil {
 		return nil, fmt.Errorf("encode: %w", err)
@@ -144,13 +207,13 @@ func main() {
 	}
 
 	// Create VM pool once
-	vms := make([]*wasmedge.VM, maxWorkers)
+	vms := make([]*workerVM, maxWorkers)
 	for i := 0; i < maxWorkers; i++ {
-		vms[i] = newVM(wasmFilter)
+		vms[i] = newWorkerVM(wasmFilter)
 	}
 	defer func() {
-		for _, vm := range vms {
-			vm.Release()
+		for _, w := range vms {
+			w.release()
 		}
 	}()
 
@@ -163,7 +226,7 @@ func main() {
 	fmt.Println("Done")
 }
 
-func processImage(file string, vms []*wasmedge.VM) {
+func processImage(file string, vms []*workerVM) {
 	fmt.Printf("→ %s\n", file)
 	src, err := imaging.Open(file)
 	checkErr(err)
@@ -172,13 +235,17 @@ func processImage(file string, vms []*wasmedge.VM) {
 	cols := (b.Dx() + tileSize - 1) / tileSize
 	rows := (b.Dy() + tileSize - 1) / tileSize
 
+	// Pre-allocated stitching canvas: raw-ABI workers write their tile
+	// straight into it, so there's no per-tile image.Image to paste.
+	final := image.NewNRGBA(b)
+
 	type task struct {
 		x, y int
-		tile image.Image
+		tile *image.NRGBA
 	}
 	type result struct {
 		x, y int
-		img  image.Image
+		img  image.Image // only set for the PNG-fallback path
 		err  error
 	}
 
@@ -186,47 +253,42 @@ func processImage(file string, vms []*wasmedge.VM) {
 	results := make(chan result)
 
 	// launch workers
-	for i, vm := range vms {
-		go func(vm *wasmedge.VM) {
+	for _, w := range vms {
+		go func(w *workerVM) {
 			for t := range tasks {
-				img, err := runTile(vm, t.tile)
+				origin := image.Pt(t.x*tileSize, t.y*tileSize)
+				if w.rawABI {
+					err := runTileRaw(w, t.tile, final, origin)
+					results <- result{t.x, t.y, nil, err}
+					continue
+				}
+				img, err := runTilePNG(w, t.tile)
 				results <- result{t.x, t.y, img, err}
 			}
-		}(vm)
-		_ = i
+		}(w)
 	}
 
 	// dispatch
 	go func() {
 		for y := 0; y < rows; y++ {
 			for x := 0; x < cols; x++ {
-				t := imaging.Crop(src, image.Rect(x*tileSize, y*tileSize,
-					x*tileSize+tileSize, y*tileSize+tileSize).Intersect(b))
-				tasks <- task{x, y, t}
+				rect := image.Rect(x*tileSize, y*tileSize, x*tileSize+tileSize, y*tileSize+tileSize).Intersect(b)
+				tasks <- task{x, y, tilebuf.Crop(src, rect)}
 			}
 		}
 		close(tasks)
 	}()
 
-	tiles := make([][]image.Image, rows)
-	for i := range tiles {
-		tiles[i] = make([]image.Image, cols)
-	}
-
-	// collect
+	// collect; PNG-fallback results still need pasting into final, raw
+	// results are already there.
 	for i := 0; i < rows*cols; i++ {
 		res := <-results
 		if res.err != nil {
 			log.Fatalf("tile %d,%d error: %v", res.x, res.y, res.err)
 		}
-		tiles[res.y][res.x] = res.img
-	}
-
-	// stitch
-	final := imaging.New(b.Dx(), b.Dy(), color.NRGBA{0, 0, 0, 0})
-	for y := 0; y < rows; y++ {
-		for x := 0; x < cols; x++ {
-			final = imaging.Paste(final, tiles[y][x], image.Pt(x*tileSize, y*tileSize))
+		if res.img != nil {
+			origin := image.Pt(res.x*tileSize, res.y*tileSize)
+			draw.Draw(final, res.img.Bounds().Add(origin), res.img, image.Point{}, draw.Over)
 		}
 	}
 
@@ -0,0 +1,139 @@
+// Command runner is the per-index entrypoint for an Indexed-completion-mode
+// batch Job (see kube.CreateTileBatchJob): it looks up its own tile from the
+// manifest ConfigMap via JOB_COMPLETION_INDEX, fetches it, runs it through
+// pkg/runtime against the containerd daemon mounted into the pod, and
+// uploads the result.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/PhantomInTheWire/wasm-image-pipeline/pkg/kube"
+	wasmruntime "github.com/PhantomInTheWire/wasm-image-pipeline/pkg/runtime"
+	"github.com/PhantomInTheWire/wasm-image-pipeline/pkg/wasmartifact"
+)
+
+// defaultRuntimeImage is the generic containerd-wasm-shims executor used to
+// run whatever filter.wasm pkg/wasmartifact pulls in; it never changes
+// between pipeline runs, only the filter module does.
+const defaultRuntimeImage = "ghcr.io/phantominthewire/wasm-runtime:latest"
+
+// artifactDir is the emptyDir mount where the pulled filter.wasm is staged.
+const artifactDir = "/opt/filter"
+
+// defaultManifestDir is where the tile manifest ConfigMap is mounted.
+const defaultManifestDir = "/opt/manifest"
+
+func getenv(key string) string {
+	v := os.Getenv(key)
+	if v == "" {
+		log.Fatalf("missing required env var %s", key)
+	}
+	return v
+}
+
+func main() {
+	wasmArtifactRef := getenv("WASM_ARTIFACT_REF")
+	runtimeImage := os.Getenv("RUNTIME_IMAGE")
+	if runtimeImage == "" {
+		runtimeImage = defaultRuntimeImage
+	}
+	containerdSock := os.Getenv("CONTAINERD_ADDRESS")
+	if containerdSock == "" {
+		containerdSock = "/run/containerd/containerd.sock"
+	}
+	manifestDir := os.Getenv("MANIFEST_DIR")
+	if manifestDir == "" {
+		manifestDir = defaultManifestDir
+	}
+
+	index, err := strconv.Atoi(getenv("JOB_COMPLETION_INDEX"))
+	if err != nil {
+		log.Fatalf("invalid JOB_COMPLETION_INDEX: %v", err)
+	}
+
+	tileRef, err := loadTileRef(manifestDir, index)
+	if err != nil {
+		log.Fatalf("load tile manifest entry %d: %v", index, err)
+	}
+
+	ctx := context.Background()
+
+	wasmPath, err := wasmartifact.Pull(ctx, wasmartifact.NewResolver(), wasmArtifactRef, artifactDir)
+	if err != nil {
+		log.Fatalf("pull wasm artifact: %v", err)
+	}
+
+	tile, err := fetch(tileRef.InputURL)
+	if err != nil {
+		log.Fatalf("fetch tile: %v", err)
+	}
+
+	client, err := wasmruntime.New(containerdSock)
+	if err != nil {
+		log.Fatalf("connect to containerd: %v", err)
+	}
+	defer client.Close()
+
+	out, err := client.RunWasmFile(ctx, runtimeImage, wasmPath, tile)
+	if err != nil {
+		log.Fatalf("run tile: %v", err)
+	}
+
+	if err := put(tileRef.OutputURL, out); err != nil {
+		log.Fatalf("upload result: %v", err)
+	}
+
+	fmt.Printf("tile %d (%s) processed\n", index, tileRef.Name)
+}
+
+// loadTileRef reads this pod's entry out of the manifest ConfigMap mounted
+// at manifestDir.
+func loadTileRef(manifestDir string, index int) (kube.TileRef, error) {
+	data, err := os.ReadFile(filepath.Join(manifestDir, kube.ManifestKey(index)))
+	if err != nil {
+		return kube.TileRef{}, err
+	}
+	var tr kube.TileRef
+	if err := json.Unmarshal(data, &tr); err != nil {
+		return kube.TileRef{}, err
+	}
+	return tr, nil
+}
+
+func fetch(url string) ([]byte, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func put(url string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
@@ -2,11 +2,13 @@ package kube
 
 import (
     "context"
+    "encoding/json"
     "fmt"
 
     batchv1 "k8s.io/api/batch/v1"
     corev1 "k8s.io/api/core/v1"
     meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+    "k8s.io/apimachinery/pkg/types"
     "k8s.io/client-go/kubernetes"
     "k8s.io/client-go/tools/clientcmd"
     "k8s.io/client-go/util/retry"
@@ -14,11 +16,38 @@ import (
 
 func int32Ptr(i int32) *int32 { return &i }
 
-// CreateJobForTile creates a Kubernetes Job that:
-// 1) downloads the tile from MinIO
-// 2) runs filter.wasm on it via Runwasi
-// 3) uploads the processed tile back to MinIO
-func CreateJobForTile(jobName, tileName, namespace, bucketURL, wasmBucketURL string) error {
+// containerdSocketPath is where the host's containerd socket is bind-mounted
+// into the processor pod, so pkg/runtime can drive it via the runwasi
+// runtime handler without a privileged docker-in-docker sidecar.
+const containerdSocketPath = "/run/containerd/containerd.sock"
+
+// manifestMountPath is where the per-tile manifest ConfigMap is mounted
+// into the pod.
+const manifestMountPath = "/opt/manifest"
+
+// completionIndexAnnotation is the pod annotation Kubernetes stamps with
+// this pod's index for an Indexed-completion-mode Job.
+const completionIndexAnnotation = "metadata.annotations['batch.kubernetes.io/job-completion-index']"
+
+// TileRef is one tile's input/output location. CreateTileBatchJob writes
+// one of these, keyed by ManifestKey(index), into the Job's manifest
+// ConfigMap; each pod reads its own entry back using JOB_COMPLETION_INDEX.
+type TileRef struct {
+    Name      string `json:"name"`
+    InputURL  string `json:"inputUrl"`
+    OutputURL string `json:"outputUrl"`
+}
+
+// ManifestKey is the ConfigMap key (and mounted file name) for tile index i.
+func ManifestKey(i int) string {
+    return fmt.Sprintf("tile-%d.json", i)
+}
+
+// CreateTileBatchJob creates a single Indexed-completion-mode Job that
+// processes all of tileRefs: one ConfigMap entry per tile, dereferenced by
+// each pod via its JOB_COMPLETION_INDEX, rather than one Job (and one
+// kube-apiserver round trip) per tile.
+func CreateTileBatchJob(jobName string, tileRefs []TileRef, wasmArtifactRef, namespace string, parallelism int32) error {
     // Load kubeconfig
     cfg, err := clientcmd.BuildConfigFromFlags("", clientcmd.RecommendedHomeFile)
     if err != nil {
@@ -29,6 +58,33 @@ func CreateJobForTile(jobName, tileName, namespace, bucketURL, wasmBucketURL str
         return fmt.Errorf("building clientset: %w", err)
     }
 
+    manifestData := make(map[string]string, len(tileRefs))
+    for i, tr := range tileRefs {
+        b, err := json.Marshal(tr)
+        if err != nil {
+            return fmt.Errorf("marshaling tile manifest entry %d: %w", i, err)
+        }
+        manifestData[ManifestKey(i)] = string(b)
+    }
+
+    configMapName := jobName + "-manifest"
+    configMap := &corev1.ConfigMap{
+        ObjectMeta: meta.ObjectMeta{
+            Name:      configMapName,
+            Namespace: namespace,
+            Labels:    map[string]string{"app": "wasm-tile-processor", "job-name": jobName},
+        },
+        Data: manifestData,
+    }
+    createdConfigMap, err := clientset.CoreV1().ConfigMaps(namespace).Create(context.Background(), configMap, meta.CreateOptions{})
+    if err != nil {
+        return fmt.Errorf("creating tile manifest configmap: %w", err)
+    }
+
+    hostPathSocket := corev1.HostPathSocket
+    completions := int32(len(tileRefs))
+    completionMode := batchv1.IndexedCompletion
+
     // Job spec
     job := &batchv1.Job{
         ObjectMeta: meta.ObjectMeta{
@@ -37,7 +93,10 @@ func CreateJobForTile(jobName, tileName, namespace, bucketURL, wasmBucketURL str
             Labels:    map[string]string{"app": "wasm-tile-processor"},
         },
         Spec: batchv1.JobSpec{
-            BackoffLimit: int32Ptr(1),
+            BackoffLimit:   int32Ptr(1),
+            Completions:    &completions,
+            Parallelism:    &parallelism,
+            CompletionMode: &completionMode,
             Template: corev1.PodTemplateSpec{
                 ObjectMeta: meta.ObjectMeta{
                     Labels: map[string]string{"job-name": jobName},
@@ -45,63 +104,97 @@ func CreateJobForTile(jobName, tileName, namespace, bucketURL, wasmBucketURL str
                 Spec: corev1.PodSpec{
                     RestartPolicy: corev1.RestartPolicyOnFailure,
 
-                    // 1) InitContainer to download filter.wasm
-                    InitContainers: []corev1.Container{{
-                        Name:  "init-wasm",
-                        Image: "curlimages/curl:7.85.0",
-                        Command: []string{
-                            "sh", "-c",
-                            fmt.Sprintf(
-                                "mkdir -p /opt/filter && "+
-                                "curl -s %s/filter.wasm -o /opt/filter/filter.wasm && "+
-                                "ls -l /opt/filter && echo \"WASM fetched!\"",
-                                wasmBucketURL,
-                            ),
-                        },
-                        VolumeMounts: []corev1.VolumeMount{{
-                            Name:      "wasm-volume",
-                            MountPath: "/opt/filter",
-                        }},
-                    }},
-
-                    // 2) Main processing container
                     Containers: []corev1.Container{{
                         Name:  "processor",
-                        Image: "ghcr.io/phantominthewire/image-pipeline:latest",
-                        Command: []string{
-                            "sh", "-c",
-                            fmt.Sprintf(
-                                "curl -s %s/%s | runwasi /opt/filter/filter.wasm > /tmp/out.png && "+
-                                "curl -X PUT -T /tmp/out.png %s/processed/%s",
-                                bucketURL, tileName,
-                                bucketURL, tileName,
-                            ),
-                        },
+                        Image: "ghcr.io/phantominthewire/tile-runner:latest",
                         Env: []corev1.EnvVar{
-                            {Name: "INPUT_URL", Value: fmt.Sprintf("%s/%s", bucketURL, tileName)},
-                            {Name: "OUTPUT_URL",Value: fmt.Sprintf("%s/processed/%s", bucketURL, tileName)},
+                            {Name: "WASM_ARTIFACT_REF", Value: wasmArtifactRef},
+                            {Name: "CONTAINERD_ADDRESS", Value: containerdSocketPath},
+                            {Name: "MANIFEST_DIR", Value: manifestMountPath},
+                            {
+                                Name: "JOB_COMPLETION_INDEX",
+                                ValueFrom: &corev1.EnvVarSource{
+                                    FieldRef: &corev1.ObjectFieldSelector{
+                                        FieldPath: completionIndexAnnotation,
+                                    },
+                                },
+                            },
+                        },
+                        VolumeMounts: []corev1.VolumeMount{
+                            {Name: "containerd-socket", MountPath: containerdSocketPath},
+                            {Name: "tile-manifest", MountPath: manifestMountPath},
                         },
-                        VolumeMounts: []corev1.VolumeMount{{
-                            Name:      "wasm-volume",
-                            MountPath: "/opt/filter",
-                        }},
                     }},
 
-                    // 3) Shared emptyDir for the wasm artifact
-                    Volumes: []corev1.Volume{{
-                        Name: "wasm-volume",
-                        VolumeSource: corev1.VolumeSource{
-                            EmptyDir: &corev1.EmptyDirVolumeSource{},
+                    Volumes: []corev1.Volume{
+                        // containerd socket from the host, so the runner can
+                        // talk to the same daemon the local orchestrator uses.
+                        {
+                            Name: "containerd-socket",
+                            VolumeSource: corev1.VolumeSource{
+                                HostPath: &corev1.HostPathVolumeSource{
+                                    Path: containerdSocketPath,
+                                    Type: &hostPathSocket,
+                                },
+                            },
                         },
-                    }},
+                        // per-index tile manifest, so each pod can look up
+                        // its own input/output URLs.
+                        {
+                            Name: "tile-manifest",
+                            VolumeSource: corev1.VolumeSource{
+                                ConfigMap: &corev1.ConfigMapVolumeSource{
+                                    LocalObjectReference: corev1.LocalObjectReference{Name: configMapName},
+                                },
+                            },
+                        },
+                    },
                 },
             },
         },
     }
 
     // Create with retry
-    return retry.RetryOnConflict(retry.DefaultRetry, func() error {
-        _, err := clientset.BatchV1().Jobs(namespace).Create(context.Background(), job, meta.CreateOptions{})
-        return err
+    var createdJob *batchv1.Job
+    err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+        var createErr error
+        createdJob, createErr = clientset.BatchV1().Jobs(namespace).Create(context.Background(), job, meta.CreateOptions{})
+        return createErr
     })
+    if err != nil {
+        // The manifest configmap has no owner yet (the Job that would own it
+        // never came into existence), so it won't be garbage-collected on its
+        // own: clean it up ourselves rather than leaving it orphaned.
+        if delErr := clientset.CoreV1().ConfigMaps(namespace).Delete(context.Background(), configMapName, meta.DeleteOptions{}); delErr != nil {
+            return fmt.Errorf("creating tile batch job: %w (also failed to clean up orphaned manifest configmap: %v)", err, delErr)
+        }
+        return fmt.Errorf("creating tile batch job: %w", err)
+    }
+
+    // Make the Job the controller owner of the manifest configmap so
+    // Kubernetes garbage-collects both together instead of leaking a
+    // configmap per pipeline run.
+    ownerRefs := []meta.OwnerReference{{
+        APIVersion:         "batch/v1",
+        Kind:               "Job",
+        Name:               createdJob.Name,
+        UID:                createdJob.UID,
+        Controller:         boolPtr(true),
+        BlockOwnerDeletion: boolPtr(true),
+    }}
+    patch, err := json.Marshal(map[string]interface{}{
+        "metadata": map[string]interface{}{
+            "ownerReferences": ownerRefs,
+        },
+    })
+    if err != nil {
+        return fmt.Errorf("marshaling configmap owner reference patch: %w", err)
+    }
+    if _, err := clientset.CoreV1().ConfigMaps(namespace).Patch(context.Background(), createdConfigMap.Name, types.MergePatchType, patch, meta.PatchOptions{}); err != nil {
+        return fmt.Errorf("setting owner reference on tile manifest configmap: %w", err)
+    }
+
+    return nil
 }
+
+func boolPtr(b bool) *bool { return &b }
@@ -0,0 +1,174 @@
+// Package runtime drives a containerd daemon to execute wasm filters
+// through the io.containerd.runwasi.v1 runtime handler. It replaces the
+// old pattern of shelling out to `docker run` or piping tile bytes
+// through `curl | runwasi` in a Job container: callers get a typed
+// Client and structured errors instead of parsing CombinedOutput.
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/cio"
+	"github.com/containerd/containerd/namespaces"
+	"github.com/containerd/containerd/oci"
+	"github.com/google/uuid"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// Namespace isolates our containers/images from anything else running
+// on the same containerd daemon.
+const Namespace = "wasm-image-pipeline"
+
+// RuntimeHandler is the containerd runtime shim that executes wasm
+// images directly, without a guest Linux kernel.
+const RuntimeHandler = "io.containerd.runwasi.v1"
+
+// ErrorKind classifies where in the pull/run pipeline a failure
+// occurred, so callers can branch on it instead of matching strings.
+type ErrorKind string
+
+const (
+	ErrPullFailed       ErrorKind = "pull-failed"
+	ErrTaskCreateFailed ErrorKind = "task-create-failed"
+	ErrTaskExitNonzero  ErrorKind = "task-exit-nonzero"
+)
+
+// Error wraps an underlying containerd error with the stage it
+// occurred at.
+type Error struct {
+	Kind ErrorKind
+	Err  error
+}
+
+func (e *Error) Error() string { return fmt.Sprintf("runtime: %s: %v", e.Kind, e.Err) }
+func (e *Error) Unwrap() error { return e.Err }
+
+// Client drives a single containerd daemon.
+type Client struct {
+	cc *containerd.Client
+}
+
+// New dials the containerd socket at address (e.g.
+// /run/containerd/containerd.sock).
+func New(address string) (*Client, error) {
+	cc, err := containerd.New(address)
+	if err != nil {
+		return nil, fmt.Errorf("dial containerd at %s: %w", address, err)
+	}
+	return &Client{cc: cc}, nil
+}
+
+// Close releases the underlying containerd connection.
+func (c *Client) Close() error { return c.cc.Close() }
+
+// RunTile resolves and pulls imageRef (a wasm filter packaged as an OCI
+// image) into the local content store, then runs it under the runwasi
+// handler against tileData via a WASI preopen mount, returning the
+// processed tile bytes.
+func (c *Client) RunTile(ctx context.Context, imageRef string, tileData []byte) ([]byte, error) {
+	dir, err := stageTileDir(tileData)
+	if err != nil {
+		return nil, &Error{Kind: ErrTaskCreateFailed, Err: fmt.Errorf("stage tile dir: %w", err)}
+	}
+	defer os.RemoveAll(dir)
+
+	return c.pullAndRun(ctx, imageRef, dir)
+}
+
+// RunWasmFile runs wasmPath (a filter.wasm already pulled and digest-verified
+// by pkg/wasmartifact, e.g. into a Job's emptyDir) against tileData. Unlike
+// RunTile, the wasm module isn't baked into its own image: runtimeImage is a
+// generic containerd-wasm-shims executor that reads /tile/filter.wasm and
+// /tile/in.png from the preopen mount, so the module never has to be
+// re-published as an image to be run.
+func (c *Client) RunWasmFile(ctx context.Context, runtimeImage, wasmPath string, tileData []byte) ([]byte, error) {
+	dir, err := stageTileDir(tileData)
+	if err != nil {
+		return nil, &Error{Kind: ErrTaskCreateFailed, Err: fmt.Errorf("stage tile dir: %w", err)}
+	}
+	defer os.RemoveAll(dir)
+
+	wasmBytes, err := os.ReadFile(wasmPath)
+	if err != nil {
+		return nil, &Error{Kind: ErrTaskCreateFailed, Err: fmt.Errorf("read wasm module: %w", err)}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "filter.wasm"), wasmBytes, 0o644); err != nil {
+		return nil, &Error{Kind: ErrTaskCreateFailed, Err: fmt.Errorf("stage wasm module: %w", err)}
+	}
+
+	return c.pullAndRun(ctx, runtimeImage, dir)
+}
+
+// pullAndRun pulls imageRef, runs it under the runwasi handler with dir
+// bind-mounted at /tile, and returns the bytes it wrote to /tile/out.png.
+func (c *Client) pullAndRun(ctx context.Context, imageRef, dir string) ([]byte, error) {
+	ctx = namespaces.WithNamespace(ctx, Namespace)
+
+	image, err := c.cc.Pull(ctx, imageRef, containerd.WithPullUnpack)
+	if err != nil {
+		return nil, &Error{Kind: ErrPullFailed, Err: err}
+	}
+
+	// A wall-clock timestamp collides easily when many goroutines call
+	// pullAndRun around the same instant (one per tile); uuid guarantees
+	// uniqueness across concurrent callers on the same *Client.
+	id := "tile-" + uuid.NewString()
+	container, err := c.cc.NewContainer(ctx, id,
+		containerd.WithImage(image),
+		containerd.WithNewSnapshot(id+"-snapshot", image),
+		containerd.WithRuntime(RuntimeHandler, nil),
+		containerd.WithNewSpec(
+			oci.WithImageConfig(image),
+			oci.WithMounts([]specs.Mount{{
+				Type:        "bind",
+				Source:      dir,
+				Destination: "/tile",
+				Options:     []string{"rbind", "rw"},
+			}}),
+		),
+	)
+	if err != nil {
+		return nil, &Error{Kind: ErrTaskCreateFailed, Err: err}
+	}
+	defer container.Delete(ctx, containerd.WithSnapshotCleanup)
+
+	task, err := container.NewTask(ctx, cio.NewCreator(cio.WithStdio))
+	if err != nil {
+		return nil, &Error{Kind: ErrTaskCreateFailed, Err: err}
+	}
+	defer task.Delete(ctx)
+
+	exitCh, err := task.Wait(ctx)
+	if err != nil {
+		return nil, &Error{Kind: ErrTaskCreateFailed, Err: err}
+	}
+	if err := task.Start(ctx); err != nil {
+		return nil, &Error{Kind: ErrTaskCreateFailed, Err: err}
+	}
+
+	status := <-exitCh
+	if code := status.ExitCode(); code != 0 {
+		return nil, &Error{Kind: ErrTaskExitNonzero, Err: fmt.Errorf("exit code %d", code)}
+	}
+
+	return os.ReadFile(filepath.Join(dir, "out.png"))
+}
+
+// stageTileDir writes tileData to in.png inside a fresh temp dir that
+// becomes the container's /tile preopen, so the wasm guest can read its
+// input and write out.png next to it.
+func stageTileDir(tileData []byte) (string, error) {
+	dir, err := os.MkdirTemp("", "wasm-tile-")
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "in.png"), tileData, 0o644); err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	return dir, nil
+}
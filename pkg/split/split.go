@@ -1,57 +1,84 @@
 package split
 
 import (
-  "fmt"
-  "image"
-  "image/png"
-  "os"
-  "path/filepath"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+
+	"github.com/PhantomInTheWire/wasm-image-pipeline/pkg/tilebuf"
 )
 
-// Image splits image at inPath into N×N tiles in outDir,
-// returns slice of tile filenames
-func Image(inPath string, outDir string, n int) ([]string, error) {
-  file, err := os.Open(inPath)
-  if err != nil {
-    return nil, err
-  }
-  defer file.Close()
-  img, _, err := image.Decode(file)
-  if err != nil {
-    return nil, err
-  }
-
-  bounds := img.Bounds()
-  w, h := bounds.Dx(), bounds.Dy()
-  tw, th := w/n, h/n
-
-  if err := os.MkdirAll(outDir, 0755); err != nil {
-    return nil, err
-  }
-
-  tiles := []string{}
-  tileID := 0
-  for y := 0; y < n; y++ {
-    for x := 0; x < n; x++ {
-      rect := image.Rect(x*tw, y*th, (x+1)*tw, (y+1)*th).Intersect(bounds)
-      sub := img.(interface {
-        SubImage(r image.Rectangle) image.Image
-      }).SubImage(rect)
-
-      outFile := filepath.Join(outDir, fmt.Sprintf("tile_%d.png", tileID))
-      f, err := os.Create(outFile)
-      if err != nil {
-        return nil, err
-      }
-      if err := png.Encode(f, sub); err != nil {
-        f.Close()
-        return nil, err
-      }
-      f.Close()
-
-      tiles = append(tiles, filepath.Base(outFile))
-      tileID++
-    }
-  }
-  return tiles, nil
+// Tile is one in-memory tile cropped from the source image. Image is a
+// zero-copy view (see tilebuf.Crop) over the source's pixel buffer, not a
+// copy, so tiles stay cheap to create even for large grids.
+type Tile struct {
+	Name  string
+	Image *image.NRGBA
+	Rect  image.Rectangle
+}
+
+// Image splits the image at inPath into n×n in-memory tiles. It no longer
+// writes PNG files itself: the wasm raw-pixel ABI and pkg/tilebuf want the
+// raw NRGBA buffer directly, and PNG-encoding every tile up front was pure
+// overhead for callers that never touch disk. Callers that still need tile
+// files (e.g. to hand off to MinIO) use SaveTiles explicitly.
+func Image(inPath string, n int) ([]Tile, error) {
+	file, err := os.Open(inPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	src, _, err := image.Decode(file)
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := src.Bounds()
+	img := image.NewNRGBA(bounds)
+	draw.Draw(img, bounds, src, bounds.Min, draw.Src)
+
+	w, h := bounds.Dx(), bounds.Dy()
+	tw, th := w/n, h/n
+
+	tiles := make([]Tile, 0, n*n)
+	tileID := 0
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			rect := image.Rect(x*tw, y*th, (x+1)*tw, (y+1)*th).Intersect(bounds)
+			tiles = append(tiles, Tile{
+				Name:  fmt.Sprintf("tile_%d.png", tileID),
+				Image: tilebuf.Crop(img, rect),
+				Rect:  rect,
+			})
+			tileID++
+		}
+	}
+	return tiles, nil
+}
+
+// SaveTiles PNG-encodes each tile to outDir/<tile.Name>, returning the
+// written filenames.
+func SaveTiles(tiles []Tile, outDir string) ([]string, error) {
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(tiles))
+	for _, t := range tiles {
+		outPath := filepath.Join(outDir, t.Name)
+		f, err := os.Create(outPath)
+		if err != nil {
+			return nil, err
+		}
+		if err := png.Encode(f, t.Image); err != nil {
+			f.Close()
+			return nil, err
+		}
+		f.Close()
+		names = append(names, t.Name)
+	}
+	return names, nil
 }
@@ -7,11 +7,11 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/credentials"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
 )
 
 type MinioConfig struct {
@@ -22,71 +22,224 @@ type MinioConfig struct {
 	Bucket    string
 	Prefix    string
 	Dir       string
+	UseSSL    bool
+
+	// PartSize is the multipart upload chunk size, in bytes. Defaults to
+	// 5 MiB when zero.
+	PartSize uint64
+	// Workers is the number of concurrent uploads. Defaults to 8 when zero.
+	Workers int
+
+	// PollInterval controls the ListObjectsV2 fallback used by
+	// ListenTileEvents when the endpoint doesn't support bucket
+	// notifications. Defaults to 5s when zero.
+	PollInterval time.Duration
 }
 
-func UploadTiles(cfg MinioConfig) error {
-	customResolver := aws.EndpointResolverWithOptionsFunc(func(service, region string, options ...any) (aws.Endpoint, error) {
-		return aws.Endpoint{
-			URL:               cfg.Endpoint,
-			SigningRegion:     cfg.Region,
-			HostnameImmutable: true,
-		}, nil
+func newClient(cfg MinioConfig) (*minio.Client, error) {
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure: cfg.UseSSL,
+		Region: cfg.Region,
 	})
+	if err != nil {
+		return nil, fmt.Errorf("building minio client: %w", err)
+	}
+	return client, nil
+}
 
-	awsCfg, err := config.LoadDefaultConfig(context.TODO(),
-		config.WithRegion(cfg.Region),
-		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(cfg.AccessKey, cfg.SecretKey, "")),
-		config.WithEndpointResolverWithOptions(customResolver),
-	)
+// UploadResult is the outcome of uploading a single tile.
+type UploadResult struct {
+	Key  string
+	ETag string
+}
+
+// UploadTilesParallel uploads every PNG tile in cfg.Dir to cfg.Bucket using
+// cfg.Workers concurrent multipart PUTs of cfg.PartSize each, returning the
+// per-object ETags so callers can verify what actually landed.
+func UploadTilesParallel(cfg MinioConfig) ([]UploadResult, error) {
+	client, err := newClient(cfg)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	client := s3.NewFromConfig(awsCfg)
+	ctx := context.Background()
 
-	// Ensure the bucket exists
-	_, err = client.HeadBucket(context.TODO(), &s3.HeadBucketInput{
-		Bucket: aws.String(cfg.Bucket),
-	})
+	exists, err := client.BucketExists(ctx, cfg.Bucket)
 	if err != nil {
-		_, err = client.CreateBucket(context.TODO(), &s3.CreateBucketInput{
-			Bucket: aws.String(cfg.Bucket),
-		})
-		if err != nil {
-			return fmt.Errorf("failed to create bucket %s: %w", cfg.Bucket, err)
+		return nil, fmt.Errorf("checking bucket %s: %w", cfg.Bucket, err)
+	}
+	if !exists {
+		if err := client.MakeBucket(ctx, cfg.Bucket, minio.MakeBucketOptions{Region: cfg.Region}); err != nil {
+			return nil, fmt.Errorf("creating bucket %s: %w", cfg.Bucket, err)
 		}
 		log.Printf("Created bucket: %s", cfg.Bucket)
 	}
 
 	files, err := os.ReadDir(cfg.Dir)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
+	var names []string
 	for _, f := range files {
 		if f.IsDir() || !strings.HasSuffix(f.Name(), ".png") {
 			continue
 		}
+		names = append(names, f.Name())
+	}
 
-		fpath := filepath.Join(cfg.Dir, f.Name())
-		file, err := os.Open(fpath)
-		if err != nil {
-			log.Printf("could not open file %s: %v", f.Name(), err)
-			continue
+	partSize := cfg.PartSize
+	if partSize == 0 {
+		partSize = 5 * 1024 * 1024
+	}
+	workers := cfg.Workers
+	if workers <= 0 {
+		workers = 8
+	}
+	if workers > len(names) {
+		workers = len(names)
+	}
+
+	jobs := make(chan string)
+	var mu sync.Mutex
+	var results []UploadResult
+	var firstErr error
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range jobs {
+				fpath := filepath.Join(cfg.Dir, name)
+				info, err := client.FPutObject(ctx, cfg.Bucket, filepath.Join(cfg.Prefix, name), fpath,
+					minio.PutObjectOptions{PartSize: partSize})
+				mu.Lock()
+				if err != nil {
+					log.Printf("failed to upload %s: %v", name, err)
+					if firstErr == nil {
+						firstErr = fmt.Errorf("uploading %s: %w", name, err)
+					}
+				} else {
+					log.Printf("uploaded: %s", name)
+					results = append(results, UploadResult{Key: info.Key, ETag: info.ETag})
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, name := range names {
+		jobs <- name
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results, firstErr
+}
+
+// Event describes a tile that became available in the bucket, either via a
+// live ListenTileEvents notification or the ListObjectsV2 poll fallback.
+type Event struct {
+	Key  string
+	Size int64
+	ETag string
+	Err  error
+}
+
+// ListenTileEvents subscribes to s3:ObjectCreated:* notifications for
+// bucket/prefix so the controller can dispatch Jobs as tiles land instead of
+// walking the directory up front. When the endpoint doesn't speak MinIO's
+// notification API (e.g. a generic S3-compatible store), it falls back to
+// polling ListObjectsV2 on cfg.PollInterval.
+func ListenTileEvents(ctx context.Context, cfg MinioConfig) (<-chan Event, error) {
+	client, err := newClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan Event)
+	go func() {
+		defer close(out)
+
+		notifications := client.ListenBucketNotification(ctx, cfg.Bucket, cfg.Prefix, "",
+			[]string{"s3:ObjectCreated:*"})
+
+		for notification := range notifications {
+			if notification.Err != nil {
+				if isNotificationUnsupported(notification.Err) {
+					log.Printf("bucket notifications unsupported (%v), falling back to polling", notification.Err)
+					pollTileEvents(ctx, client, cfg, out)
+					return
+				}
+				select {
+				case out <- Event{Err: notification.Err}:
+				case <-ctx.Done():
+					return
+				}
+				continue
+			}
+			for _, record := range notification.Records {
+				ev := Event{
+					Key:  record.S3.Object.Key,
+					Size: record.S3.Object.Size,
+					ETag: record.S3.Object.ETag,
+				}
+				select {
+				case out <- ev:
+				case <-ctx.Done():
+					return
+				}
+			}
 		}
-		defer file.Close()
-
-		_, err = client.PutObject(context.TODO(), &s3.PutObjectInput{
-			Bucket: aws.String(cfg.Bucket),
-			Key:    aws.String(filepath.Join(cfg.Prefix, f.Name())),
-			Body:   file,
-		})
-		if err != nil {
-			log.Printf("failed to upload %s: %v", f.Name(), err)
-		} else {
-			log.Printf("uploaded: %s", f.Name())
+	}()
+
+	return out, nil
+}
+
+// pollTileEvents walks ListObjectsV2 on an interval, emitting an Event the
+// first time each key is observed.
+func pollTileEvents(ctx context.Context, client *minio.Client, cfg MinioConfig, out chan<- Event) {
+	interval := cfg.PollInterval
+	if interval == 0 {
+		interval = 5 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	seen := make(map[string]struct{})
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for obj := range client.ListObjects(ctx, cfg.Bucket, minio.ListObjectsOptions{Prefix: cfg.Prefix}) {
+				if obj.Err != nil {
+					select {
+					case out <- Event{Err: obj.Err}:
+					case <-ctx.Done():
+						return
+					}
+					continue
+				}
+				if _, ok := seen[obj.Key]; ok {
+					continue
+				}
+				seen[obj.Key] = struct{}{}
+				select {
+				case out <- Event{Key: obj.Key, Size: obj.Size, ETag: obj.ETag}:
+				case <-ctx.Done():
+					return
+				}
+			}
 		}
 	}
+}
 
-	return nil
+// isNotificationUnsupported reports whether err looks like the endpoint
+// rejected ListenBucketNotification because it isn't MinIO (generic S3
+// implementations return NotImplemented for this API).
+func isNotificationUnsupported(err error) bool {
+	return strings.Contains(err.Error(), "NotImplemented") || strings.Contains(err.Error(), "not implemented")
 }
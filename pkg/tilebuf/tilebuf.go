@@ -0,0 +1,78 @@
+// Package tilebuf supports the grayscale_raw WASI ABI: it crops tiles
+// without copying pixel data, stages them into a wasm module's linear
+// memory that's allocated once per worker and reused across tiles, and
+// reads results straight into a pre-allocated stitching canvas. None of
+// these steps allocate an intermediate image.Image.
+package tilebuf
+
+import (
+	"fmt"
+	"image"
+)
+
+// Crop returns the sub-image of img at rect. Because *image.NRGBA.SubImage
+// just narrows Rect over the same Pix/Stride, this does not copy pixels.
+func Crop(img *image.NRGBA, rect image.Rectangle) *image.NRGBA {
+	return img.SubImage(rect).(*image.NRGBA)
+}
+
+// Memory is the subset of wasmedge.Memory tilebuf needs, so this package
+// doesn't have to import wasmedge-go directly.
+type Memory interface {
+	GetData(offset, length uint) ([]byte, error)
+}
+
+// ModuleBuffer is a linear-memory region a worker allocates once (via the
+// module's `alloc` export) and reuses across every tile it processes.
+type ModuleBuffer struct {
+	Ptr int32
+	Cap int32
+}
+
+// WriteTile copies tile's raw NRGBA pixels into buf's backing wasm memory,
+// packing rows contiguously, and returns the row stride the guest should use
+// to interpret them.
+//
+// tile.Pix can't be used directly: SubImage only narrows Rect over the
+// source image's full Pix/Stride, so tile.Pix still runs to the end of the
+// source buffer and len(tile.Pix) is not the tile's byte footprint. The true
+// footprint is computed from tile.Rect, and rows are copied individually
+// since tile.Stride (the source image's stride) is usually wider than the
+// tile itself.
+func WriteTile(mem Memory, buf ModuleBuffer, tile *image.NRGBA) (stride int32, err error) {
+	w, h := tile.Rect.Dx(), tile.Rect.Dy()
+	rowBytes := w * 4
+	n := rowBytes * h
+	if int32(n) > buf.Cap {
+		return 0, fmt.Errorf("tile of %d bytes exceeds %d-byte module buffer", n, buf.Cap)
+	}
+	dst, err := mem.GetData(uint(buf.Ptr), uint(n))
+	if err != nil {
+		return 0, err
+	}
+	for row := 0; row < h; row++ {
+		src := tile.Pix[row*tile.Stride : row*tile.Stride+rowBytes]
+		copy(dst[row*rowBytes:(row+1)*rowBytes], src)
+	}
+	return int32(rowBytes), nil
+}
+
+// ReadInto copies the w×h raw NRGBA result at (ptr, length) in mem directly
+// into canvas at origin, row by row, without building an intermediate
+// image.Image.
+func ReadInto(mem Memory, ptr, length int32, canvas *image.NRGBA, origin image.Point, w, h int) error {
+	data, err := mem.GetData(uint(ptr), uint(length))
+	if err != nil {
+		return err
+	}
+	rowBytes := w * 4
+	if rowBytes*h > len(data) {
+		return fmt.Errorf("raw result too small: want %d bytes, got %d", rowBytes*h, len(data))
+	}
+	for row := 0; row < h; row++ {
+		src := data[row*rowBytes : (row+1)*rowBytes]
+		dstOff := canvas.PixOffset(origin.X, origin.Y+row)
+		copy(canvas.Pix[dstOff:dstOff+rowBytes], src)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package tilebuf
+
+import (
+	"image"
+	"testing"
+)
+
+// fakeMemory is a Memory backed by a plain byte slice, standing in for a
+// wasm module's linear memory.
+type fakeMemory []byte
+
+func (m fakeMemory) GetData(offset, length uint) ([]byte, error) {
+	return m[offset : offset+length], nil
+}
+
+// TestWriteTileNonFlush covers a tile that sits in the middle of a larger
+// image, so its SubImage's Pix slice runs well past the tile's own bytes:
+// WriteTile must size and pack the copy from tile.Rect/Stride, not
+// len(tile.Pix).
+func TestWriteTileNonFlush(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	for i := range src.Pix {
+		src.Pix[i] = byte(i)
+	}
+
+	tile := Crop(src, image.Rect(2, 2, 5, 5)) // 3x3, not flush against src's edges
+
+	const cap = 64
+	mem := make(fakeMemory, cap)
+	buf := ModuleBuffer{Ptr: 0, Cap: cap}
+
+	stride, err := WriteTile(mem, buf, tile)
+	if err != nil {
+		t.Fatalf("WriteTile: %v", err)
+	}
+	if want := int32(3 * 4); stride != want {
+		t.Fatalf("stride = %d, want %d", stride, want)
+	}
+
+	rowBytes := 3 * 4
+	for row := 0; row < 3; row++ {
+		srcOff := tile.PixOffset(2, 2+row)
+		want := tile.Pix[srcOff : srcOff+rowBytes]
+		got := mem[row*rowBytes : (row+1)*rowBytes]
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("row %d byte %d = %d, want %d", row, i, got[i], want[i])
+			}
+		}
+	}
+}
+
+// TestWriteTileRejectsOversizedTile ensures the capacity check is still
+// enforced once it's based on the tile's real footprint.
+func TestWriteTileRejectsOversizedTile(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	tile := Crop(src, image.Rect(0, 0, 8, 8))
+
+	mem := make(fakeMemory, 16)
+	buf := ModuleBuffer{Ptr: 0, Cap: 16}
+
+	if _, err := WriteTile(mem, buf, tile); err == nil {
+		t.Fatal("expected error for tile exceeding module buffer capacity")
+	}
+}
@@ -0,0 +1,172 @@
+// Package wasmartifact packages filter.wasm as a single-layer OCI artifact
+// and pushes/pulls it from any OCI-Distribution registry, using the same
+// containerd remotes/docker resolver the images service relies on. This
+// replaces fetching filter.wasm over a plain `curl` from a bucket URL with
+// a content-addressable, versioned, registry-authenticated artifact.
+package wasmartifact
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/containerd/containerd/errdefs"
+	"github.com/containerd/containerd/remotes"
+	"github.com/containerd/containerd/remotes/docker"
+	"github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// MediaType of the single wasm content layer and its config blob.
+const (
+	WasmLayerMediaType  = "application/vnd.wasm.content.layer.v1+wasm"
+	WasmConfigMediaType = "application/vnd.wasm.config.v0+json"
+)
+
+// NewResolver builds the docker resolver used for both push and pull, so
+// registry auth (from the ambient docker config) is resolved identically
+// on the controller and inside Jobs.
+func NewResolver() remotes.Resolver {
+	return docker.NewResolver(docker.ResolverOptions{})
+}
+
+// Artifact describes a pushed wasm artifact, pinned to its manifest digest
+// so callers get a content-addressable reference regardless of what tag
+// ref carries.
+type Artifact struct {
+	Ref    string
+	Digest digest.Digest
+	Size   int64
+}
+
+// Push reads wasmPath off disk, wraps it as a single-layer OCI artifact,
+// and pushes config, layer and manifest to ref via resolver.
+func Push(ctx context.Context, resolver remotes.Resolver, ref, wasmPath string) (Artifact, error) {
+	wasmBytes, err := os.ReadFile(wasmPath)
+	if err != nil {
+		return Artifact{}, fmt.Errorf("read %s: %w", wasmPath, err)
+	}
+
+	pusher, err := resolver.Pusher(ctx, ref)
+	if err != nil {
+		return Artifact{}, fmt.Errorf("resolve pusher for %s: %w", ref, err)
+	}
+
+	layerDesc := ocispec.Descriptor{
+		MediaType: WasmLayerMediaType,
+		Digest:    digest.FromBytes(wasmBytes),
+		Size:      int64(len(wasmBytes)),
+	}
+	if err := pushBlob(ctx, pusher, layerDesc, wasmBytes); err != nil {
+		return Artifact{}, fmt.Errorf("push layer: %w", err)
+	}
+
+	configBytes := []byte(fmt.Sprintf(`{"mediaType":%q}`, WasmConfigMediaType))
+	configDesc := ocispec.Descriptor{
+		MediaType: WasmConfigMediaType,
+		Digest:    digest.FromBytes(configBytes),
+		Size:      int64(len(configBytes)),
+	}
+	if err := pushBlob(ctx, pusher, configDesc, configBytes); err != nil {
+		return Artifact{}, fmt.Errorf("push config: %w", err)
+	}
+
+	manifest := ocispec.Manifest{
+		Versioned: ociSchemaVersion2,
+		Config:    configDesc,
+		Layers:    []ocispec.Descriptor{layerDesc},
+	}
+	manifestBytes, err := json.Marshal(manifest)
+	if err != nil {
+		return Artifact{}, fmt.Errorf("marshal manifest: %w", err)
+	}
+	manifestDesc := ocispec.Descriptor{
+		MediaType: ocispec.MediaTypeImageManifest,
+		Digest:    digest.FromBytes(manifestBytes),
+		Size:      int64(len(manifestBytes)),
+	}
+	if err := pushBlob(ctx, pusher, manifestDesc, manifestBytes); err != nil {
+		return Artifact{}, fmt.Errorf("push manifest: %w", err)
+	}
+
+	return Artifact{Ref: ref, Digest: manifestDesc.Digest, Size: manifestDesc.Size}, nil
+}
+
+// Pull resolves ref via resolver, verifies the fetched manifest's digest
+// matches what ref resolved to, fetches the single wasm layer (again
+// verifying its digest), and writes it to destDir/filter.wasm.
+func Pull(ctx context.Context, resolver remotes.Resolver, ref, destDir string) (string, error) {
+	resolvedRef, manifestDesc, err := resolver.Resolve(ctx, ref)
+	if err != nil {
+		return "", fmt.Errorf("resolve %s: %w", ref, err)
+	}
+
+	fetcher, err := resolver.Fetcher(ctx, resolvedRef)
+	if err != nil {
+		return "", fmt.Errorf("resolve fetcher for %s: %w", resolvedRef, err)
+	}
+
+	manifestBytes, err := fetchBlob(ctx, fetcher, manifestDesc)
+	if err != nil {
+		return "", fmt.Errorf("fetch manifest: %w", err)
+	}
+	if digest.FromBytes(manifestBytes) != manifestDesc.Digest {
+		return "", fmt.Errorf("manifest digest mismatch: expected %s", manifestDesc.Digest)
+	}
+
+	var manifest ocispec.Manifest
+	if err := json.Unmarshal(manifestBytes, &manifest); err != nil {
+		return "", fmt.Errorf("decode manifest: %w", err)
+	}
+	if len(manifest.Layers) != 1 {
+		return "", fmt.Errorf("expected exactly one layer, got %d", len(manifest.Layers))
+	}
+	layerDesc := manifest.Layers[0]
+
+	wasmBytes, err := fetchBlob(ctx, fetcher, layerDesc)
+	if err != nil {
+		return "", fmt.Errorf("fetch wasm layer: %w", err)
+	}
+	if digest.FromBytes(wasmBytes) != layerDesc.Digest {
+		return "", fmt.Errorf("wasm layer digest mismatch: expected %s", layerDesc.Digest)
+	}
+
+	if err := os.MkdirAll(destDir, 0o755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(destDir, "filter.wasm")
+	if err := os.WriteFile(path, wasmBytes, 0o644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+var ociSchemaVersion2 = specs.Versioned{SchemaVersion: 2}
+
+func pushBlob(ctx context.Context, pusher remotes.Pusher, desc ocispec.Descriptor, data []byte) error {
+	w, err := pusher.Push(ctx, desc)
+	if err != nil {
+		if errdefs.IsAlreadyExists(err) {
+			return nil
+		}
+		return err
+	}
+	defer w.Close()
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	return w.Commit(ctx, desc.Size, desc.Digest)
+}
+
+func fetchBlob(ctx context.Context, fetcher remotes.Fetcher, desc ocispec.Descriptor) ([]byte, error) {
+	rc, err := fetcher.Fetch(ctx, desc)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}